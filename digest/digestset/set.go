@@ -0,0 +1,85 @@
+// Package digestset provides a set for holding unique digests which may also
+// be looked up by a short, possibly ambiguous, hex prefix, the way
+// `docker run a1b2c` resolves a short image ID to a full one.
+package digestset
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/docker/naming/digest"
+)
+
+// ErrDigestNotFound is used when no digest in the set matches a lookup.
+var ErrDigestNotFound = errors.New("digest not found")
+
+// ErrDigestAmbiguous is used when a short ID matches more than one digest
+// in the set. None of the matching digests should be considered a valid
+// match.
+var ErrDigestAmbiguous = errors.New("ambiguous digest string")
+
+// Set holds a unique collection of digests, allowing a digest to be resolved
+// either by its full value or by a short hex prefix of it.
+type Set struct {
+	mutex   sync.RWMutex
+	entries map[digest.Digest]struct{}
+}
+
+// NewSet creates an empty Set.
+func NewSet() *Set {
+	return &Set{
+		entries: make(map[digest.Digest]struct{}),
+	}
+}
+
+// Add inserts d into the set. Adding a digest already present in the set
+// is a no-op.
+func (dst *Set) Add(d digest.Digest) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	dst.entries[d] = struct{}{}
+	return nil
+}
+
+// Remove removes d from the set. Removing a digest not present in the set
+// is a no-op.
+func (dst *Set) Remove(d digest.Digest) error {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	delete(dst.entries, d)
+	return nil
+}
+
+// Lookup resolves shortID, either a full digest string or a hex prefix of
+// one, against the digests in the set. It returns ErrDigestNotFound if no
+// digest matches and ErrDigestAmbiguous if more than one digest matches the
+// prefix.
+func (dst *Set) Lookup(shortID string) (digest.Digest, error) {
+	dst.mutex.RLock()
+	defer dst.mutex.RUnlock()
+	if len(dst.entries) == 0 {
+		return "", ErrDigestNotFound
+	}
+	if full := digest.Digest(shortID); full.Validate() == nil {
+		if _, ok := dst.entries[full]; ok {
+			return full, nil
+		}
+	}
+	var match digest.Digest
+	for d := range dst.entries {
+		if strings.HasPrefix(d.Hex(), shortID) {
+			if match != "" {
+				return "", ErrDigestAmbiguous
+			}
+			match = d
+		}
+	}
+	if match == "" {
+		return "", ErrDigestNotFound
+	}
+	return match, nil
+}
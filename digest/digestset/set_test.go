@@ -0,0 +1,39 @@
+package digestset
+
+import (
+	"testing"
+
+	"github.com/docker/naming/digest"
+)
+
+func TestSetLookup(t *testing.T) {
+	a := digest.Digest("sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa")
+	b := digest.Digest("sha256:86e0e0ffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+	set := NewSet()
+	if err := set.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Add(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := set.Lookup("86e0e0"); err != ErrDigestAmbiguous {
+		t.Fatalf("Expected ErrDigestAmbiguous for shared prefix, got %v", err)
+	}
+
+	if dgst, err := set.Lookup("86e0e091"); err != nil || dgst != a {
+		t.Fatalf("Expected %q, got %q, %v", a, dgst, err)
+	}
+
+	if _, err := set.Lookup("abcdef"); err != ErrDigestNotFound {
+		t.Fatalf("Expected ErrDigestNotFound for unknown prefix, got %v", err)
+	}
+
+	if err := set.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Lookup("86e0e091"); err != ErrDigestNotFound {
+		t.Fatalf("Expected ErrDigestNotFound after removal, got %v", err)
+	}
+}
@@ -3,9 +3,11 @@ package reference
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/docker/naming/digest"
+	"github.com/docker/naming/digest/digestset"
 )
 
 const (
@@ -19,6 +21,45 @@ const (
 	DefaultRepoPrefix = "library/"
 )
 
+// NormalizationPolicy describes how a registry's bare, name-only references
+// normalize to and from their fully-qualified form. It lets downstream
+// projects that stand in their own registry as "the default" (Quay, GHCR, a
+// private mirror) configure their own familiar/normalized mappings without
+// forking this package.
+type NormalizationPolicy struct {
+	// DefaultDomain is the hostname assumed when a reference carries none.
+	DefaultDomain string
+	// LegacyDomains are hostnames that are rewritten to DefaultDomain.
+	LegacyDomains []string
+	// OfficialNamespace is the namespace prefix applied to single-component
+	// repository names in DefaultDomain, e.g. "library/".
+	OfficialNamespace string
+	// DefaultTag is the tag assumed when a reference carries neither a tag
+	// nor a digest.
+	DefaultTag string
+}
+
+// DefaultPolicy is the NormalizationPolicy implementing Docker Hub's
+// historical behavior: DefaultHostname is the default domain,
+// LegacyDefaultHostname is rewritten to it, official images live under
+// DefaultRepoPrefix, and DefaultTag is the default tag.
+var DefaultPolicy = &NormalizationPolicy{
+	DefaultDomain:     DefaultHostname,
+	LegacyDomains:     []string{LegacyDefaultHostname},
+	OfficialNamespace: DefaultRepoPrefix,
+	DefaultTag:        DefaultTag,
+}
+
+// shortIdentifierRegexp matches a hex prefix of a content digest; see the
+// digestset package doc for what that's used for.
+var shortIdentifierRegexp = regexp.MustCompile(`^[a-f0-9]{5,64}$`)
+
+// Reference is a textual reference that resolves to either a repository
+// reference (RemoteNamed and friends) or a bare content digest.
+type Reference interface {
+	String() string
+}
+
 // RemoteNamed is an object with a full name
 type RemoteNamed interface {
 	Named
@@ -43,16 +84,32 @@ type RemoteCanonical interface {
 	Digest() digest.Digest
 }
 
+// FamiliarNamed is a RemoteNamed that can also render itself in the familiar,
+// human-oriented short form produced by FamiliarName, e.g. "ubuntu" instead of
+// "docker.io/library/ubuntu".
+type FamiliarNamed interface {
+	RemoteNamed
+	// Familiar returns a copy of this reference with a shortened hostname
+	// and namespace, suitable for CLI output.
+	Familiar() Named
+}
+
 // ParseRemoteNamed parses s and returns a syntactically valid reference implementing
 // the RemoteNamed interface. The reference must have a name, otherwise an error is
 // returned.
 // If an error was encountered it is returned, along with a nil Reference.
 func ParseRemoteNamed(s string) (RemoteNamed, error) {
+	return ParseRemoteNamedWithPolicy(s, DefaultPolicy)
+}
+
+// ParseRemoteNamedWithPolicy behaves like ParseRemoteNamed, but normalizes and
+// validates s according to p instead of DefaultPolicy.
+func ParseRemoteNamedWithPolicy(s string, p *NormalizationPolicy) (RemoteNamed, error) {
 	named, err := ParseNamed(s)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing reference: %q is not a valid repository/tag", s)
 	}
-	r, err := WithRemoteName(named.Name())
+	r, err := WithRemoteNameAndPolicy(named.Name(), p)
 	if err != nil {
 		return nil, err
 	}
@@ -65,21 +122,45 @@ func ParseRemoteNamed(s string) (RemoteNamed, error) {
 	return r, nil
 }
 
+// ParseDockerRef normalizes the image reference following the docker convention. It
+// behaves like ParseRemoteNamed, so a reference carrying both a tag and a digest is
+// collapsed to a RemoteCanonical keeping only the digest, but it additionally applies
+// DefaultTag to a name-only reference, giving callers a single entry point that always
+// returns a reference in canonical form, e.g. "busybox" becomes
+// "docker.io/library/busybox:latest".
+func ParseDockerRef(s string) (RemoteNamed, error) {
+	ref, err := ParseRemoteNamed(s)
+	if err != nil {
+		return nil, err
+	}
+	return WithDefaultRemoteTag(ref), nil
+}
+
 // WithRemoteName returns a named object representing the given string. If the input
 // is invalid ErrReferenceInvalidFormat will be returned.
 func WithRemoteName(name string) (RemoteNamed, error) {
-	name, err := normalize(name)
-	if err != nil {
+	return WithRemoteNameAndPolicy(name, DefaultPolicy)
+}
+
+// WithRemoteNameAndPolicy behaves like WithRemoteName, but normalizes and
+// validates name according to p instead of DefaultPolicy.
+func WithRemoteNameAndPolicy(name string, p *NormalizationPolicy) (RemoteNamed, error) {
+	// validateName must run against the original, pre-normalized input: once
+	// normalizeWithPolicy() strips p's default domain and official namespace, a value
+	// like "docker.io/1a3f...b3a" becomes indistinguishable from the bare hex
+	// string it was meant to disambiguate from an image ID.
+	if err := validateName(name); err != nil {
 		return nil, err
 	}
-	if err := validateName(name); err != nil {
+	name, err := normalizeWithPolicy(name, p)
+	if err != nil {
 		return nil, err
 	}
 	r, err := WithName(name)
 	if err != nil {
 		return nil, err
 	}
-	return &remoteNamedRef{r}, nil
+	return &remoteNamedRef{r, p}, nil
 }
 
 // WithRemoteTag combines the name from "name" and the tag from "tag" to form a
@@ -89,7 +170,7 @@ func WithRemoteTag(name Named, tag string) (RemoteTagged, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &remoteTaggedRef{remoteNamedRef{r}}, nil
+	return &remoteTaggedRef{remoteNamedRef{r, policyOf(name)}}, nil
 }
 
 // WithRemoteDigest combines the name from "name" and the digest from "digest" to form
@@ -99,11 +180,31 @@ func WithRemoteDigest(name Named, digest digest.Digest) (RemoteCanonical, error)
 	if err != nil {
 		return nil, err
 	}
-	return &remoteCanonicalRef{remoteNamedRef{r}}, nil
+	return &remoteCanonicalRef{remoteNamedRef{r, policyOf(name)}}, nil
+}
+
+// hasNormalizationPolicy is implemented by every remoteNamedRef, including
+// through the embedding done by remoteTaggedRef and remoteCanonicalRef,
+// which promotes normalizationPolicy() onto them. Asserting against this
+// interface (rather than the concrete *remoteNamedRef type, which embedding
+// does not satisfy) is what lets policyOf see through the tagged/canonical
+// wrappers.
+type hasNormalizationPolicy interface {
+	normalizationPolicy() *NormalizationPolicy
+}
+
+// policyOf returns the NormalizationPolicy that produced named, falling back
+// to DefaultPolicy for a Named that wasn't built through this package.
+func policyOf(named Named) *NormalizationPolicy {
+	if r, ok := named.(hasNormalizationPolicy); ok {
+		return r.normalizationPolicy()
+	}
+	return DefaultPolicy
 }
 
 type remoteNamedRef struct {
 	Named
+	policy *NormalizationPolicy
 }
 type remoteTaggedRef struct {
 	remoteNamedRef
@@ -112,29 +213,86 @@ type remoteCanonicalRef struct {
 	remoteNamedRef
 }
 
+func (r *remoteNamedRef) normalizationPolicy() *NormalizationPolicy {
+	return r.policy
+}
 func (r *remoteNamedRef) FullName() string {
-	hostname, remoteName := splitHostname(r.Name())
+	hostname, remoteName := splitHostnameWithPolicy(r.Name(), r.policy)
 	return hostname + "/" + remoteName
 }
 func (r *remoteNamedRef) Hostname() string {
-	hostname, _ := splitHostname(r.Name())
+	hostname, _ := splitHostnameWithPolicy(r.Name(), r.policy)
 	return hostname
 }
 func (r *remoteNamedRef) RemoteName() string {
-	_, remoteName := splitHostname(r.Name())
+	_, remoteName := splitHostnameWithPolicy(r.Name(), r.policy)
 	return remoteName
 }
+func (r *remoteNamedRef) Familiar() Named {
+	// FamiliarName is derived from an already-valid reference and should
+	// never fail to round-trip through WithName, but fall back to r rather
+	// than chaining a nil Named into WithTag/WithDigest below.
+	if named, err := WithName(FamiliarName(r)); err == nil {
+		return named
+	}
+	return r
+}
 func (r *remoteTaggedRef) Tag() string {
 	return r.remoteNamedRef.Named.(NamedTagged).Tag()
 }
+func (r *remoteTaggedRef) Familiar() Named {
+	base := r.remoteNamedRef.Familiar()
+	if tagged, err := WithTag(base, r.Tag()); err == nil {
+		return tagged
+	}
+	return base
+}
 func (r *remoteCanonicalRef) Digest() digest.Digest {
 	return r.remoteNamedRef.Named.(Canonical).Digest()
 }
+func (r *remoteCanonicalRef) Familiar() Named {
+	base := r.remoteNamedRef.Familiar()
+	if canonical, err := WithDigest(base, r.Digest()); err == nil {
+		return canonical
+	}
+	return base
+}
+
+// FamiliarName returns the familiar name for a RemoteNamed reference, dropping
+// the default domain and, for official images, the namespace of whichever
+// NormalizationPolicy produced it. For any other Named that isn't a
+// RemoteNamed, the name is returned as is.
+func FamiliarName(named Named) string {
+	r, ok := named.(RemoteNamed)
+	if !ok {
+		return named.Name()
+	}
+	p := policyOf(named)
+	if r.Hostname() == p.DefaultDomain {
+		return strings.TrimPrefix(r.RemoteName(), p.OfficialNamespace)
+	}
+	return r.FullName()
+}
 
-// WithDefaultRemoteTag adds a default tag to a reference if it only has a repo name.
+// FamiliarString returns the familiar string representation for the given
+// reference, applying the same shortening as FamiliarName while preserving
+// any tag or digest suffix.
+func FamiliarString(ref Named) string {
+	name := FamiliarName(ref)
+	if canonical, ok := ref.(Canonical); ok {
+		return name + "@" + canonical.Digest().String()
+	}
+	if tagged, ok := ref.(NamedTagged); ok {
+		return name + ":" + tagged.Tag()
+	}
+	return name
+}
+
+// WithDefaultRemoteTag adds a default tag to a reference if it only has a repo
+// name, using the DefaultTag of the NormalizationPolicy that produced ref.
 func WithDefaultRemoteTag(ref RemoteNamed) RemoteNamed {
 	if IsRemoteNameOnly(ref) {
-		ref, _ = WithRemoteTag(ref, DefaultTag)
+		ref, _ = WithRemoteTag(ref, policyOf(ref).DefaultTag)
 	}
 	return ref
 }
@@ -163,41 +321,76 @@ func ParseIDOrReference(idOrRef string) (digest.Digest, Named, error) {
 	return "", ref, err
 }
 
-// splitHostname splits a repository name to hostname and remotename string.
-// If no valid hostname is found, the default hostname is used. Repository name
-// needs to be already validated before.
-func splitHostname(name string) (hostname, remoteName string) {
+// ParseAnyReference parses s as either a short or full identifier (see
+// shortIdentifierRegexp) or a repository reference, and returns the matching
+// Reference. It is equivalent to calling ParseAnyReferenceWithSet with a nil
+// set, so only a full hex identifier can be resolved as a digest.
+func ParseAnyReference(s string) (Reference, error) {
+	return ParseAnyReferenceWithSet(s, nil)
+}
+
+// ParseAnyReferenceWithSet behaves like ParseAnyReference, but resolves a
+// short identifier against set. This removes the ambiguity ParseIDOrReference
+// pushed onto callers by returning two values; an identifier is recognized up
+// front and resolved to a single digest.Digest, falling back to
+// ParseRemoteNamed for anything else.
+func ParseAnyReferenceWithSet(s string, set *digestset.Set) (Reference, error) {
+	if shortIdentifierRegexp.MatchString(s) {
+		if set != nil {
+			return set.Lookup(s)
+		}
+		if len(s) == 64 {
+			return digest.ParseDigest("sha256:" + s)
+		}
+		return nil, digestset.ErrDigestNotFound
+	}
+	return ParseRemoteNamed(s)
+}
+
+// splitHostnameWithPolicy splits a repository name to hostname and
+// remotename string according to p. If no valid hostname is found, p's
+// default domain is used. Repository name needs to be already validated
+// before.
+func splitHostnameWithPolicy(name string, p *NormalizationPolicy) (hostname, remoteName string) {
 	i := strings.IndexRune(name, '/')
 	if i == -1 || (!strings.ContainsAny(name[:i], ".:") && name[:i] != "localhost") {
-		hostname, remoteName = DefaultHostname, name
+		hostname, remoteName = p.DefaultDomain, name
 	} else {
 		hostname, remoteName = name[:i], name[i+1:]
 	}
-	if hostname == LegacyDefaultHostname {
-		hostname = DefaultHostname
+	for _, legacy := range p.LegacyDomains {
+		if hostname == legacy {
+			hostname = p.DefaultDomain
+			break
+		}
 	}
-	if hostname == DefaultHostname && !strings.ContainsRune(remoteName, '/') {
-		remoteName = DefaultRepoPrefix + remoteName
+	if hostname == p.DefaultDomain && !strings.ContainsRune(remoteName, '/') {
+		remoteName = p.OfficialNamespace + remoteName
 	}
 	return
 }
 
-// normalize returns a repository name in its normalized form, meaning it
-// will not contain default hostname nor library/ prefix for official images.
-func normalize(name string) (string, error) {
-	host, remoteName := splitHostname(name)
+// normalizeWithPolicy returns a repository name in its normalized form
+// according to p, meaning it will not contain p's default domain nor its
+// official namespace prefix.
+func normalizeWithPolicy(name string, p *NormalizationPolicy) (string, error) {
+	host, remoteName := splitHostnameWithPolicy(name, p)
 	if strings.ToLower(remoteName) != remoteName {
 		return "", errors.New("invalid reference format: repository name must be lowercase")
 	}
-	if host == DefaultHostname {
-		if strings.HasPrefix(remoteName, DefaultRepoPrefix) {
-			return strings.TrimPrefix(remoteName, DefaultRepoPrefix), nil
+	if host == p.DefaultDomain {
+		if strings.HasPrefix(remoteName, p.OfficialNamespace) {
+			return strings.TrimPrefix(remoteName, p.OfficialNamespace), nil
 		}
 		return remoteName, nil
 	}
 	return name, nil
 }
 
+// validateName rejects a 64-character hexadecimal value given without an
+// explicit hostname, since it would be ambiguous with an image ID. A
+// hostname-qualified name such as "docker.io/1a3f...b3a" is unambiguous and
+// is left for ParseAnyReference to tell apart from a bare identifier.
 func validateName(name string) error {
 	if err := digest.ValidateHex(name); err == nil {
 		return fmt.Errorf("Invalid repository name (%s), cannot specify 64-byte hexadecimal strings", name)
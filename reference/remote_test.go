@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/docker/naming/digest"
+	"github.com/docker/naming/digest/digestset"
 )
 
 func TestValidateReferenceName(t *testing.T) {
@@ -21,6 +22,9 @@ func TestValidateReferenceName(t *testing.T) {
 		"127.0.0.1:5000/library/debian",
 		"127.0.0.1:5000/debian",
 		"thisisthesongthatneverendsitgoesonandonandonthisisthesongthatnev",
+
+		// A 64-character hex value is unambiguous once it's hostname-qualified.
+		"docker.io/1a3f5e7d9c1b3a5f7e9d1c3b5a7f9e1d3c5b7a9f1e3d5d7c9b1a3f5e7d9c1b3a",
 	}
 	invalidRepoNames := []string{
 		"https://github.com/docker/docker",
@@ -31,8 +35,9 @@ func TestValidateReferenceName(t *testing.T) {
 		"docker///docker",
 		"docker.io/docker/Docker",
 		"docker.io/docker///docker",
+
+		// Bare 64-character hex is rejected: ParseAnyReference should handle it as an ID.
 		"1a3f5e7d9c1b3a5f7e9d1c3b5a7f9e1d3c5b7a9f1e3d5d7c9b1a3f5e7d9c1b3a",
-		"docker.io/1a3f5e7d9c1b3a5f7e9d1c3b5a7f9e1d3c5b7a9f1e3d5d7c9b1a3f5e7d9c1b3a",
 	}
 
 	for _, name := range invalidRepoNames {
@@ -258,6 +263,214 @@ func TestParseReferenceWithTagAndDigest(t *testing.T) {
 	}
 }
 
+func TestParseDockerRef(t *testing.T) {
+	ref, err := ParseDockerRef("busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, isTagged := ref.(RemoteTagged)
+	if !isTagged {
+		t.Fatalf("Reference from %q should support tag", ref)
+	}
+	if expected, actual := DefaultTag, tagged.Tag(); expected != actual {
+		t.Fatalf("Invalid default tag for %q: expected %q, got %q", ref, expected, actual)
+	}
+	if expected, actual := "docker.io/library/busybox", ref.FullName(); expected != actual {
+		t.Fatalf("Invalid full name for %q: expected %q, got %q", ref, expected, actual)
+	}
+
+	ref, err = ParseDockerRef("busybox:latest@sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, isTagged := ref.(RemoteTagged); isTagged {
+		t.Fatalf("Reference from %q should not support tag", ref)
+	}
+	canonical, isCanonical := ref.(RemoteCanonical)
+	if !isCanonical {
+		t.Fatalf("Reference from %q should support digest", ref)
+	}
+	if expected, actual := digest.Digest("sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa"), canonical.Digest(); expected != actual {
+		t.Fatalf("Invalid digest for %q: expected %q, got %q", ref, expected, actual)
+	}
+}
+
+func TestFamiliarName(t *testing.T) {
+	tcases := []struct {
+		input          string
+		familiarName   string
+		familiarString string
+	}{
+		{
+			input:          "docker.io/library/ubuntu",
+			familiarName:   "ubuntu",
+			familiarString: "ubuntu:" + DefaultTag,
+		},
+		{
+			input:          "docker.io/ubuntu",
+			familiarName:   "ubuntu",
+			familiarString: "ubuntu:" + DefaultTag,
+		},
+		{
+			input:          "docker.io/nonlibrary/ubuntu",
+			familiarName:   "nonlibrary/ubuntu",
+			familiarString: "nonlibrary/ubuntu:" + DefaultTag,
+		},
+		{
+			input:          "example.com/private/moonbase",
+			familiarName:   "example.com/private/moonbase",
+			familiarString: "example.com/private/moonbase:" + DefaultTag,
+		},
+	}
+
+	for _, tcase := range tcases {
+		ref, err := ParseDockerRef(tcase.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, actual := tcase.familiarName, FamiliarName(ref); expected != actual {
+			t.Fatalf("Invalid familiar name for %q. Expected %q, got %q", tcase.input, expected, actual)
+		}
+		if expected, actual := tcase.familiarString, FamiliarString(ref); expected != actual {
+			t.Fatalf("Invalid familiar string for %q. Expected %q, got %q", tcase.input, expected, actual)
+		}
+		familiar, ok := ref.(FamiliarNamed)
+		if !ok {
+			t.Fatalf("%q should implement FamiliarNamed", ref)
+		}
+		if expected, actual := tcase.familiarName, familiar.Familiar().Name(); expected != actual {
+			t.Fatalf("Invalid Familiar().Name() for %q. Expected %q, got %q", tcase.input, expected, actual)
+		}
+	}
+}
+
+func TestParseAnyReference(t *testing.T) {
+	fullDigest := "sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa"
+
+	ref, err := ParseAnyReference(fullDigest[len("sha256:"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := fullDigest, ref.String(); expected != actual {
+		t.Fatalf("Invalid reference for full id. Expected %q, got %q", expected, actual)
+	}
+
+	ref, err = ParseAnyReference("busybox:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ref.(RemoteTagged); !ok {
+		t.Fatalf("Reference from %q should support tag", ref)
+	}
+
+	if _, err := ParseAnyReference("a1b2c"); err != digestset.ErrDigestNotFound {
+		t.Fatalf("Expected ErrDigestNotFound for unresolved short id, got %v", err)
+	}
+}
+
+func TestParseAnyReferenceWithSet(t *testing.T) {
+	dgst := digest.Digest("sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa")
+	set := digestset.NewSet()
+	if err := set.Add(dgst); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := ParseAnyReferenceWithSet("86e0e", set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := dgst.String(), ref.String(); expected != actual {
+		t.Fatalf("Invalid reference for short id. Expected %q, got %q", expected, actual)
+	}
+
+	if _, err := ParseAnyReferenceWithSet("ffffff", set); err != digestset.ErrDigestNotFound {
+		t.Fatalf("Expected ErrDigestNotFound for unknown short id, got %v", err)
+	}
+}
+
+func TestParseRemoteNamedWithPolicy(t *testing.T) {
+	quay := &NormalizationPolicy{
+		DefaultDomain:     "quay.io",
+		OfficialNamespace: "official/",
+		DefaultTag:        DefaultTag,
+	}
+
+	ref, err := ParseRemoteNamedWithPolicy("coreos/etcd", quay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := "quay.io/coreos/etcd", ref.FullName(); expected != actual {
+		t.Fatalf("Invalid full name. Expected %q, got %q", expected, actual)
+	}
+	if expected, actual := "quay.io", ref.Hostname(); expected != actual {
+		t.Fatalf("Invalid hostname. Expected %q, got %q", expected, actual)
+	}
+
+	ref, err = ParseRemoteNamedWithPolicy("etcd", quay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := "quay.io/official/etcd", ref.FullName(); expected != actual {
+		t.Fatalf("Invalid full name for official namespace. Expected %q, got %q", expected, actual)
+	}
+	if expected, actual := "etcd", FamiliarName(ref); expected != actual {
+		t.Fatalf("Invalid familiar name. Expected %q, got %q", expected, actual)
+	}
+
+	// Parsing through the default, unqualified functions keeps using
+	// DefaultPolicy and must not be affected by the custom policy above.
+	defaultRef, err := ParseRemoteNamed("etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := "docker.io/library/etcd", defaultRef.FullName(); expected != actual {
+		t.Fatalf("Invalid full name. Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestParseRemoteNamedWithPolicyPreservedThroughTagAndDigest(t *testing.T) {
+	quay := &NormalizationPolicy{
+		DefaultDomain:     "quay.io",
+		OfficialNamespace: "official/",
+		DefaultTag:        "stable",
+	}
+
+	tagged, err := ParseRemoteNamedWithPolicy("coreos/etcd:v3", quay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tagged.(RemoteTagged); !ok {
+		t.Fatalf("Reference from %q should support tag", tagged)
+	}
+	if expected, actual := "coreos/etcd", FamiliarName(tagged); expected != actual {
+		t.Fatalf("Invalid familiar name for tagged reference. Expected %q, got %q", expected, actual)
+	}
+	if expected, actual := "coreos/etcd:v3", FamiliarString(tagged); expected != actual {
+		t.Fatalf("Invalid familiar string for tagged reference. Expected %q, got %q", expected, actual)
+	}
+
+	canonical, err := WithRemoteDigest(tagged, digest.Digest("sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := "quay.io/coreos/etcd", canonical.FullName(); expected != actual {
+		t.Fatalf("Invalid full name for %q. Expected %q, got %q", canonical, expected, actual)
+	}
+
+	nameOnly, err := ParseRemoteNamedWithPolicy("coreos/etcd", quay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withDefaultTag := WithDefaultRemoteTag(nameOnly)
+	taggedWithDefault, ok := withDefaultTag.(RemoteTagged)
+	if !ok {
+		t.Fatalf("Reference from %q should support tag", withDefaultTag)
+	}
+	if expected, actual := quay.DefaultTag, taggedWithDefault.Tag(); expected != actual {
+		t.Fatalf("Invalid default tag from policy. Expected %q, got %q", expected, actual)
+	}
+}
+
 func TestInvalidReferenceComponents(t *testing.T) {
 	if _, err := WithRemoteName("-foo"); err == nil {
 		t.Fatal("Expected WithRemoteName to detect invalid name")